@@ -0,0 +1,125 @@
+package opendrive
+
+import "fmt"
+
+// Error is returned in the body of the response when a request fails
+type Error struct {
+	ErrorField struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Error satisfies the error interface
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (%d)", e.ErrorField.Message, e.ErrorField.Code)
+}
+
+// Account describes a login request
+type Account struct {
+	Username string `json:"username"`
+	Password string `json:"passwd"`
+}
+
+// UserSessionInfo describes a successful login response
+type UserSessionInfo struct {
+	Username  string `json:"username"`
+	UserID    string `json:"UserID"`
+	SessionID string `json:"SessionID"`
+}
+
+// Folder describes an OpenDRIVE folder
+type Folder struct {
+	FolderID     string `json:"FolderID"`
+	Name         string `json:"Name"`
+	DateModified int64  `json:"DateModified"`
+}
+
+// File describes an OpenDRIVE file
+type File struct {
+	FileID       string `json:"FileID"`
+	Name         string `json:"Name"`
+	Size         int64  `json:"Size"`
+	DateModified int64  `json:"DateModified"`
+	MD5          string `json:"FileHash"`
+}
+
+// FolderList is the response from the folder listing call
+type FolderList struct {
+	Folders []Folder `json:"Folders"`
+	Files   []File   `json:"Files"`
+}
+
+// CreateFileRequest is passed to /upload/create_file.json
+type CreateFileRequest struct {
+	SessionID string `json:"session_id"`
+	FolderID  string `json:"folder_id"`
+	Name      string `json:"file_name"`
+	Size      int64  `json:"file_size"`
+}
+
+// CreateFileResponse is returned by /upload/create_file.json
+type CreateFileResponse struct {
+	FileID       string `json:"FileId"`
+	TempLocation string `json:"TempLocation"`
+}
+
+// UploadFileChunkReply is returned by /upload/upload_file_chunk.json
+type UploadFileChunkReply struct {
+	TotalWritten int64 `json:"TotalWritten"`
+}
+
+// CloseUploadRequest is passed to /upload/close_file_upload.json
+type CloseUploadRequest struct {
+	SessionID    string `json:"session_id"`
+	FileID       string `json:"file_id"`
+	Size         int64  `json:"file_size"`
+	TempLocation string `json:"temp_location"`
+	FileHash     string `json:"file_hash"`
+}
+
+// CloseUploadResponse is returned by /upload/close_file_upload.json
+type CloseUploadResponse struct {
+	FileID string `json:"FileId"`
+}
+
+// CreateFolderRequest is passed to /folder.json
+type CreateFolderRequest struct {
+	SessionID       string `json:"session_id"`
+	FolderName      string `json:"folder_name"`
+	FolderSubParent string `json:"folder_sub_parent"`
+}
+
+// CreateFolderResponse is returned by /folder.json
+type CreateFolderResponse struct {
+	FolderID string `json:"FolderID"`
+	Name     string `json:"Name"`
+}
+
+// RemoveFolderRequest is passed to /folder/remove.json
+type RemoveFolderRequest struct {
+	SessionID string `json:"session_id"`
+	FolderID  string `json:"folder_id"`
+}
+
+// MoveCopyFileRequest is passed to /file/move_copy.json
+type MoveCopyFileRequest struct {
+	SessionID   string `json:"session_id"`
+	SrcFileID   string `json:"src_file_id"`
+	DstFolderID string `json:"dst_folder_id"`
+	Name        string `json:"new_file_name"`
+	Move        bool   `json:"move"`
+}
+
+// MoveCopyFileResponse is returned by /file/move_copy.json
+type MoveCopyFileResponse struct {
+	FileID string `json:"FileID"`
+}
+
+// MoveCopyFolderRequest is passed to /folder/move_copy.json
+type MoveCopyFolderRequest struct {
+	SessionID   string `json:"session_id"`
+	FolderID    string `json:"folder_id"`
+	DstFolderID string `json:"dst_folder_id"`
+	Name        string `json:"new_folder_name"`
+}
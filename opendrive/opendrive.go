@@ -1,12 +1,18 @@
 package opendrive
 
 import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
-	"fmt"
-
 	"github.com/ncw/rclone/dircache"
 	"github.com/ncw/rclone/fs"
 	"github.com/ncw/rclone/pacer"
@@ -15,14 +21,19 @@ import (
 )
 
 const (
-	defaultEndpoint = "https://dev.opendrive.com/api/v1"
-	minSleep        = 10 * time.Millisecond
-	maxSleep        = 5 * time.Minute
-	decayConstant   = 1 // bigger for slower decay, exponential
-	maxParts        = 10000
-	maxVersions     = 100 // maximum number of versions we search in --b2-versions mode
+	defaultEndpoint   = "https://dev.opendrive.com/api/v1"
+	minSleep          = 10 * time.Millisecond
+	maxSleep          = 5 * time.Minute
+	decayConstant     = 1 // bigger for slower decay, exponential
+	maxParts          = 10000
+	maxVersions       = 100             // maximum number of versions we search in --b2-versions mode
+	keepAliveInterval = 5 * time.Minute // interval to ping the session so it doesn't expire
 )
 
+// defaultChunkSize is the size of the chunks used to upload a file when
+// upload_chunk_size isn't set in the config
+var defaultChunkSize = fs.SizeSuffix(10 * 1024 * 1024)
+
 // Register with Fs
 func init() {
 	fs.Register(&fs.RegInfo{
@@ -36,20 +47,26 @@ func init() {
 			Name:       "password",
 			Help:       "Password.",
 			IsPassword: true,
+		}, {
+			Name:    "upload_chunk_size",
+			Help:    "Files will be uploaded in chunks this size. Note that these chunks are buffered in memory.",
+			Default: defaultChunkSize,
 		}},
 	})
 }
 
 // Fs represents a remote b2 server
 type Fs struct {
-	name     string             // name of this remote
-	features *fs.Features       // optional features
-	username string             // account name
-	password string             // auth key0
-	srv      *rest.Client       // the connection to the b2 server
-	pacer    *pacer.Pacer       // To pace and retry the API calls
-	session  UserSessionInfo    // contains the session data
-	dirCache *dircache.DirCache // Map of directory path to directory id
+	name            string             // name of this remote
+	features        *fs.Features       // optional features
+	username        string             // account name
+	password        string             // auth key0
+	srv             *rest.Client       // the connection to the b2 server
+	pacer           *pacer.Pacer       // To pace and retry the API calls
+	session         UserSessionInfo    // contains the session data
+	sessionMu       sync.RWMutex       // protects session (renewed concurrently with reads)
+	dirCache        *dircache.DirCache // Map of directory path to directory id
+	uploadChunkSize fs.SizeSuffix      // size of the chunks used to upload files
 }
 
 // Object describes a b2 object
@@ -111,12 +128,24 @@ func NewFs(name, root string) (fs.Fs, error) {
 	fs.Debugf(nil, "OpenDRIVE-user: %s", username)
 	fs.Debugf(nil, "OpenDRIVE-pass: %s", password)
 
+	uploadChunkSize := defaultChunkSize
+	if chunkSizeString := fs.ConfigFileGet(name, "upload_chunk_size"); chunkSizeString != "" {
+		if err = uploadChunkSize.Set(chunkSizeString); err != nil {
+			return nil, errors.Wrap(err, "failed to parse upload_chunk_size")
+		}
+	}
+	if uploadChunkSize <= 0 {
+		fs.Debugf(name, "upload_chunk_size must be positive, using default of %v instead", defaultChunkSize)
+		uploadChunkSize = defaultChunkSize
+	}
+
 	f := &Fs{
-		name:     name,
-		username: username,
-		password: password,
-		srv:      rest.NewClient(fs.Config.Client()).SetErrorHandler(errorHandler),
-		pacer:    pacer.New().SetMinSleep(minSleep).SetMaxSleep(maxSleep).SetDecayConstant(decayConstant),
+		name:            name,
+		username:        username,
+		password:        password,
+		srv:             rest.NewClient(fs.Config.Client()).SetErrorHandler(errorHandler),
+		pacer:           pacer.New().SetMinSleep(minSleep).SetMaxSleep(maxSleep).SetDecayConstant(decayConstant),
+		uploadChunkSize: uploadChunkSize,
 	}
 
 	f.dirCache = dircache.New(root, "0", f)
@@ -124,25 +153,16 @@ func NewFs(name, root string) (fs.Fs, error) {
 	// set the rootURL for the REST client
 	f.srv.SetRoot(defaultEndpoint)
 
-	// get sessionID
-	var resp *http.Response
-	err = f.pacer.Call(func() (bool, error) {
-		account := Account{Username: username, Password: password}
-
-		opts := rest.Opts{
-			Method: "POST",
-			Path:   "/session/login.json",
-		}
-		resp, err = f.srv.CallJSON(&opts, &account, &f.session)
-		return f.shouldRetry(resp, err)
-	})
+	f.sessionMu.Lock()
+	err = f.login()
+	f.sessionMu.Unlock()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create session")
 	}
 
-	fs.Debugf(nil, "Starting OpenDRIVE session with ID: %s", f.session.SessionID)
+	go f.keepAlive()
 
-	// f.features = (&fs.Features{ReadMimeType: true, WriteMimeType: true}).Fill(f)
+	f.features = (&fs.Features{}).Fill(f)
 	// // Set the test flag if required
 	// if *b2TestMode != "" {
 	// 	testMode := strings.TrimSpace(*b2TestMode)
@@ -185,95 +205,154 @@ func NewFs(name, root string) (fs.Fs, error) {
 
 // errorHandler parses a non 2xx error response into an error
 func errorHandler(resp *http.Response) error {
-	// Decode error response
-	// errResponse := new(api.Error)
-	// err := rest.DecodeJSON(resp, &errResponse)
-	// if err != nil {
-	// 	fs.Debugf(nil, "Couldn't decode error response: %v", err)
-	// }
-	// if errResponse.Code == "" {
-	// 	errResponse.Code = "unknown"
-	// }
-	// if errResponse.Status == 0 {
-	// 	errResponse.Status = resp.StatusCode
-	// }
-	// if errResponse.Message == "" {
-	// 	errResponse.Message = "Unknown " + resp.Status
-	// }
-	// return errResponse
+	errResponse := new(Error)
+	err := rest.DecodeJSON(resp, errResponse)
+	if err != nil {
+		fs.Debugf(nil, "Couldn't decode error response: %v", err)
+	}
+	if errResponse.ErrorField.Message == "" {
+		errResponse.ErrorField.Message = resp.Status
+	}
+	if errResponse.ErrorField.Code == 0 {
+		errResponse.ErrorField.Code = resp.StatusCode
+	}
+	return errResponse
+}
+
+// sessionID returns the current session ID. Safe for concurrent use - every
+// handler in this file should read the session through this accessor rather
+// than touching f.session directly, since renewSession can replace it from
+// another goroutine at any time.
+func (f *Fs) sessionID() string {
+	f.sessionMu.RLock()
+	defer f.sessionMu.RUnlock()
+	return f.session.SessionID
+}
+
+// login authenticates with OpenDRIVE and stores the resulting session.
+// Callers must hold sessionMu for writing.
+func (f *Fs) login() error {
+	var resp *http.Response
+	var session UserSessionInfo
+	err := f.pacer.Call(func() (bool, error) {
+		account := Account{Username: f.username, Password: f.password}
+		opts := rest.Opts{
+			Method: "POST",
+			Path:   "/session/login.json",
+		}
+		var err error
+		resp, err = f.srv.CallJSON(&opts, &account, &session)
+		// Don't go through f.shouldRetry here: it would try to renew the
+		// session we are in the middle of creating, deadlocking on sessionMu.
+		return fs.ShouldRetry(err) || fs.ShouldRetryHTTP(resp, retryErrorCodes), err
+	})
+	if err != nil {
+		return err
+	}
+	f.session = session
+	fs.Debugf(f, "Starting OpenDRIVE session with ID: %s", f.session.SessionID)
 	return nil
 }
 
-// Mkdir creates the bucket if it doesn't exist
+// renewSession re-authenticates if the session that just failed
+// (expiredSessionID) is still the current one - if another concurrent
+// caller has already renewed it there is nothing to do
+func (f *Fs) renewSession(expiredSessionID string) error {
+	f.sessionMu.Lock()
+	defer f.sessionMu.Unlock()
+	if f.session.SessionID != expiredSessionID {
+		return nil
+	}
+	return f.login()
+}
+
+// keepAlive pings the session periodically so that it doesn't expire while
+// idle during a long running transfer
+func (f *Fs) keepAlive() {
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		err := f.pacer.Call(func() (bool, error) {
+			opts := rest.Opts{
+				Method:     "GET",
+				Path:       "/session/exists.json/" + f.sessionID(),
+				NoResponse: true,
+			}
+			resp, err := f.srv.Call(&opts)
+			return f.shouldRetry(resp, err)
+		})
+		if err != nil {
+			fs.Debugf(f, "Failed to keep session alive: %v", err)
+		}
+	}
+}
+
+// isSessionExpired returns true if err indicates the OpenDRIVE session has
+// expired or is otherwise no longer valid
+func isSessionExpired(err error) bool {
+	apiErr, ok := errors.Cause(err).(*Error)
+	if !ok {
+		return false
+	}
+	return strings.Contains(strings.ToLower(apiErr.ErrorField.Message), "session")
+}
+
+// Mkdir creates the directory and any intermediate directories that
+// don't exist yet
 func (f *Fs) Mkdir(dir string) error {
-	// // Can't create subdirs
-	// if dir != "" {
-	// 	return nil
-	// }
-	// opts := rest.Opts{
-	// 	Method: "POST",
-	// 	Path:   "/b2_create_bucket",
-	// }
-	// var request = api.CreateBucketRequest{
-	// 	AccountID: f.info.AccountID,
-	// 	Name:      f.bucket,
-	// 	Type:      "allPrivate",
-	// }
-	// var response api.Bucket
-	// err := f.pacer.Call(func() (bool, error) {
-	// 	resp, err := f.srv.CallJSON(&opts, &request, &response)
-	// 	return f.shouldRetry(resp, err)
-	// })
-	// if err != nil {
-	// 	if apiErr, ok := err.(*api.Error); ok {
-	// 		if apiErr.Code == "duplicate_bucket_name" {
-	// 			// Check this is our bucket - buckets are globally unique and this
-	// 			// might be someone elses.
-	// 			_, getBucketErr := f.getBucketID()
-	// 			if getBucketErr == nil {
-	// 				// found so it is our bucket
-	// 				return nil
-	// 			}
-	// 			if getBucketErr != fs.ErrorDirNotFound {
-	// 				fs.Debugf(f, "Error checking bucket exists: %v", getBucketErr)
-	// 			}
-	// 		}
-	// 	}
-	// 	return errors.Wrap(err, "failed to create bucket")
-	// }
-	// f.setBucketID(response.ID)
-	return nil
+	_, err := f.dirCache.FindDir(dir, true)
+	return err
 }
 
-// Rmdir deletes the bucket if the fs is at the root
+// Rmdir deletes the directory
 //
 // Returns an error if it isn't empty
 func (f *Fs) Rmdir(dir string) error {
-	// if f.root != "" || dir != "" {
-	// 	return nil
-	// }
-	// opts := rest.Opts{
-	// 	Method: "POST",
-	// 	Path:   "/b2_delete_bucket",
-	// }
-	// bucketID, err := f.getBucketID()
-	// if err != nil {
-	// 	return err
-	// }
-	// var request = api.DeleteBucketRequest{
-	// 	ID:        bucketID,
-	// 	AccountID: f.info.AccountID,
-	// }
-	// var response api.Bucket
-	// err = f.pacer.Call(func() (bool, error) {
-	// 	resp, err := f.srv.CallJSON(&opts, &request, &response)
-	// 	return f.shouldRetry(resp, err)
-	// })
-	// if err != nil {
-	// 	return errors.Wrap(err, "failed to delete bucket")
-	// }
-	// f.clearBucketID()
-	// f.clearUploadURL()
+	if dir == "" {
+		return errors.New("can't remove root directory")
+	}
+
+	directoryID, err := f.dirCache.FindDir(dir, false)
+	if err != nil {
+		return err
+	}
+	if directoryID == "0" {
+		return errors.New("can't remove root directory")
+	}
+
+	var resp *http.Response
+	folderList := FolderList{}
+	err = f.pacer.Call(func() (bool, error) {
+		opts := rest.Opts{
+			Method: "GET",
+			Path:   "/folder/list.json/" + f.sessionID() + "/" + directoryID,
+		}
+		resp, err = f.srv.CallJSON(&opts, nil, &folderList)
+		return f.shouldRetry(resp, err)
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to get folder list")
+	}
+	if len(folderList.Folders) > 0 || len(folderList.Files) > 0 {
+		return errors.New("directory not empty")
+	}
+
+	err = f.pacer.Call(func() (bool, error) {
+		opts := rest.Opts{
+			Method: "POST",
+			Path:   "/folder/remove.json",
+		}
+		var request = RemoveFolderRequest{
+			SessionID: f.sessionID(),
+			FolderID:  directoryID,
+		}
+		resp, err = f.srv.CallJSON(&opts, &request, nil)
+		return f.shouldRetry(resp, err)
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to remove directory")
+	}
+	f.dirCache.FlushDir(dir)
 	return nil
 }
 
@@ -288,16 +367,64 @@ func (f *Fs) Precision() time.Duration {
 func (f *Fs) newObjectWithInfo(remote string, file *File) (fs.Object, error) {
 	fs.Debugf(nil, "newObjectWithInfo(%s, %v)", remote, file)
 	o := &Object{
-		fs:      f,
-		remote:  remote,
-		id:      file.FileID,
-		modTime: time.Unix(file.DateModified, 0),
-		size:    file.Size,
+		fs:     f,
+		remote: remote,
+	}
+	if file != nil {
+		o.setMetaData(file)
+		return o, nil
+	}
+	if err := o.readMetaData(); err != nil {
+		return nil, err
 	}
-
 	return o, nil
 }
 
+// setMetaData sets the metadata from info returned from the server
+func (o *Object) setMetaData(file *File) {
+	o.id = file.FileID
+	o.modTime = time.Unix(file.DateModified, 0)
+	o.size = file.Size
+	o.md5 = file.MD5
+}
+
+// readMetaData resolves remote to a file ID via dirCache/FindLeaf and
+// fetches its metadata, populating the object. It returns
+// fs.ErrorObjectNotFound if remote doesn't exist.
+func (o *Object) readMetaData() error {
+	leaf, directoryID, err := o.fs.dirCache.FindPath(o.remote, false)
+	if err != nil {
+		if err == fs.ErrorDirNotFound {
+			return fs.ErrorObjectNotFound
+		}
+		return err
+	}
+
+	fileID, found, err := o.fs.FindLeaf(directoryID, leaf)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fs.ErrorObjectNotFound
+	}
+
+	var resp *http.Response
+	var file File
+	err = o.fs.pacer.Call(func() (bool, error) {
+		opts := rest.Opts{
+			Method: "GET",
+			Path:   "/file/info.json/" + o.fs.sessionID() + "/" + fileID,
+		}
+		resp, err = o.fs.srv.CallJSON(&opts, nil, &file)
+		return o.fs.shouldRetry(resp, err)
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to get file info")
+	}
+	o.setMetaData(&file)
+	return nil
+}
+
 // NewObject finds the Object at remote.  If it can't be found
 // it returns the error fs.ErrorObjectNotFound.
 func (f *Fs) NewObject(remote string) (fs.Object, error) {
@@ -311,12 +438,32 @@ func (f *Fs) NewObject(remote string) (fs.Object, error) {
 // The new object may have been created if an error is returned
 func (f *Fs) Put(in io.Reader, src fs.ObjectInfo) (fs.Object, error) {
 	// Temporary Object under construction
-	// fs := &Object{
-	// 	fs:     f,
-	// 	remote: src.Remote(),
-	// }
-	// return fs, fs.Update(in, src)
-	return nil, nil
+	o := &Object{
+		fs:     f,
+		remote: src.Remote(),
+	}
+	return o, o.Update(in, src)
+}
+
+// removeFailedUpload cleans up a file that was partially uploaded after an
+// error. Failures are only logged since the original error is the one that
+// matters to the caller.
+func (f *Fs) removeFailedUpload(fileID string) {
+	if fileID == "" {
+		return
+	}
+	err := f.pacer.Call(func() (bool, error) {
+		opts := rest.Opts{
+			Method:     "DELETE",
+			Path:       "/file.json/" + f.sessionID() + "/" + fileID,
+			NoResponse: true,
+		}
+		resp, err := f.srv.Call(&opts)
+		return f.shouldRetry(resp, err)
+	})
+	if err != nil {
+		fs.Debugf(f, "Failed to remove failed upload %q: %v", fileID, err)
+	}
 }
 
 // retryErrorCodes is a slice of error codes that we will retry
@@ -334,20 +481,13 @@ var retryErrorCodes = []int{
 // shouldRetry returns a boolean as to whether this resp and err
 // deserve to be retried.  It returns the err as a convenience
 func (f *Fs) shouldRetry(resp *http.Response, err error) (bool, error) {
-	// if resp != nil {
-	// 	if resp.StatusCode == 401 {
-	// 		f.tokenRenewer.Invalidate()
-	// 		fs.Debugf(f, "401 error received - invalidating token")
-	// 		return true, err
-	// 	}
-	// 	// Work around receiving this error sporadically on authentication
-	// 	//
-	// 	// HTTP code 403: "403 Forbidden", reponse body: {"message":"Authorization header requires 'Credential' parameter. Authorization header requires 'Signature' parameter. Authorization header requires 'SignedHeaders' parameter. Authorization header requires existence of either a 'X-Amz-Date' or a 'Date' header. Authorization=Bearer"}
-	// 	if resp.StatusCode == 403 && strings.Contains(err.Error(), "Authorization header requires") {
-	// 		fs.Debugf(f, "403 \"Authorization header requires...\" error received - retry")
-	// 		return true, err
-	// 	}
-	// }
+	if (resp != nil && resp.StatusCode == 401) || isSessionExpired(err) {
+		fs.Debugf(f, "Session expired - re-authenticating")
+		if loginErr := f.renewSession(f.sessionID()); loginErr != nil {
+			return false, errors.Wrap(loginErr, "failed to renew session")
+		}
+		return true, err
+	}
 	return fs.ShouldRetry(err) || fs.ShouldRetryHTTP(resp, retryErrorCodes), err
 }
 
@@ -356,21 +496,26 @@ func (f *Fs) shouldRetry(resp *http.Response, err error) (bool, error) {
 // CreateDir makes a directory with pathID as parent and name leaf
 func (f *Fs) CreateDir(pathID, leaf string) (newID string, err error) {
 	fs.Debugf(nil, "CreateDir(\"%s\", \"%s\")", pathID, leaf)
-	// //fmt.Printf("CreateDir(%q, %q)\n", pathID, leaf)
-	// folder := acd.FolderFromId(pathID, f.c.Nodes)
-	// var resp *http.Response
-	// var info *acd.Folder
-	// err = f.pacer.Call(func() (bool, error) {
-	// 	info, resp, err = folder.CreateFolder(leaf)
-	// 	return f.shouldRetry(resp, err)
-	// })
-	// if err != nil {
-	// 	//fmt.Printf("...Error %v\n", err)
-	// 	return "", err
-	// }
-	// //fmt.Printf("...Id %q\n", *info.Id)
-	// return *info.Id, nil
-	return "", fmt.Errorf("CreateDir not implemented")
+
+	var resp *http.Response
+	var response CreateFolderResponse
+	err = f.pacer.Call(func() (bool, error) {
+		opts := rest.Opts{
+			Method: "POST",
+			Path:   "/folder.json",
+		}
+		var request = CreateFolderRequest{
+			SessionID:       f.sessionID(),
+			FolderName:      leaf,
+			FolderSubParent: pathID,
+		}
+		resp, err = f.srv.CallJSON(&opts, &request, &response)
+		return f.shouldRetry(resp, err)
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create directory")
+	}
+	return response.FolderID, nil
 }
 
 // FindLeaf finds a directory of name leaf in the folder with ID pathID
@@ -388,7 +533,7 @@ func (f *Fs) FindLeaf(pathID, leaf string) (pathIDOut string, found bool, err er
 	err = f.pacer.Call(func() (bool, error) {
 		opts := rest.Opts{
 			Method: "GET",
-			Path:   "/folder/list.json/" + f.session.SessionID + "/" + pathID,
+			Path:   "/folder/list.json/" + f.sessionID() + "/" + pathID,
 		}
 		resp, err = f.srv.CallJSON(&opts, nil, &folderList)
 		return f.shouldRetry(resp, err)
@@ -425,7 +570,7 @@ func (f *Fs) ListDir(out fs.ListOpts, job dircache.ListDirJob) (jobs []dircache.
 	err = f.pacer.Call(func() (bool, error) {
 		opts := rest.Opts{
 			Method: "GET",
-			Path:   "/folder/list.json/" + f.session.SessionID + "/" + job.DirID,
+			Path:   "/folder/list.json/" + f.sessionID() + "/" + job.DirID,
 		}
 		resp, err = f.srv.CallJSON(&opts, nil, &folderList)
 		return f.shouldRetry(resp, err)
@@ -467,6 +612,125 @@ func (f *Fs) ListDir(out fs.ListOpts, job dircache.ListDirJob) (jobs []dircache.
 	return jobs, nil
 }
 
+// Copy src to this remote using server side copy operations.
+//
+// This is stored with the remote path given
+//
+// It returns the destination Object and a possible error
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantCopy
+func (f *Fs) Copy(src fs.Object, remote string) (fs.Object, error) {
+	return f.copyOrMove(src, remote, false)
+}
+
+// Move src to this remote using server side move operations.
+//
+// This is stored with the remote path given
+//
+// It returns the destination Object and a possible error
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantMove
+func (f *Fs) Move(src fs.Object, remote string) (fs.Object, error) {
+	return f.copyOrMove(src, remote, true)
+}
+
+// copyOrMove resolves the destination folder ID via dirCache (creating it
+// if needed) and issues a single /file/move_copy.json call, which OpenDRIVE
+// uses for both operations depending on the move flag. The source is sent
+// by its file ID only; its parent folder is not looked up.
+func (f *Fs) copyOrMove(src fs.Object, remote string, move bool) (fs.Object, error) {
+	srcObj, ok := src.(*Object)
+	if !ok {
+		fs.Debugf(src, "Can't copy/move - not same remote type")
+		if move {
+			return nil, fs.ErrorCantMove
+		}
+		return nil, fs.ErrorCantCopy
+	}
+
+	dstLeaf, dstDirectoryID, err := f.dirCache.FindPath(remote, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp *http.Response
+	var response MoveCopyFileResponse
+	err = f.pacer.Call(func() (bool, error) {
+		opts := rest.Opts{
+			Method: "POST",
+			Path:   "/file/move_copy.json",
+		}
+		request := MoveCopyFileRequest{
+			SessionID:   f.sessionID(),
+			SrcFileID:   srcObj.id,
+			DstFolderID: dstDirectoryID,
+			Name:        dstLeaf,
+			Move:        move,
+		}
+		resp, err = f.srv.CallJSON(&opts, &request, &response)
+		return f.shouldRetry(resp, err)
+	})
+	if err != nil {
+		if move {
+			return nil, errors.Wrap(err, "failed to move file")
+		}
+		return nil, errors.Wrap(err, "failed to copy file")
+	}
+
+	dstObj := &Object{fs: f, remote: remote}
+	dstObj.setMetaData(&File{
+		FileID:       response.FileID,
+		Size:         srcObj.size,
+		DateModified: srcObj.modTime.Unix(),
+		MD5:          srcObj.md5,
+	})
+	return dstObj, nil
+}
+
+// DirMove moves src, srcRemote to this remote at dstRemote using server
+// side directory move operations.
+//
+// Will only be called if src.Features().DirMove is the same as f.Features().DirMove
+//
+// If it isn't possible then return fs.ErrorCantDirMove
+func (f *Fs) DirMove(src fs.Fs, srcRemote, dstRemote string) error {
+	srcFs, ok := src.(*Fs)
+	if !ok {
+		fs.Debugf(srcFs, "Can't move directory - not same remote type")
+		return fs.ErrorCantDirMove
+	}
+
+	srcID, _, _, dstDirectoryID, dstLeaf, err := f.dirCache.DirMove(srcFs.dirCache, srcRemote, dstRemote)
+	if err != nil {
+		return err
+	}
+
+	err = f.pacer.Call(func() (bool, error) {
+		opts := rest.Opts{
+			Method: "POST",
+			Path:   "/folder/move_copy.json",
+		}
+		request := MoveCopyFolderRequest{
+			SessionID:   f.sessionID(),
+			FolderID:    srcID,
+			DstFolderID: dstDirectoryID,
+			Name:        dstLeaf,
+		}
+		resp, err := f.srv.CallJSON(&opts, &request, nil)
+		return f.shouldRetry(resp, err)
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to move directory")
+	}
+
+	srcFs.dirCache.FlushDir(srcRemote)
+	return nil
+}
+
 // ------------------------------------------------------------
 
 // Fs returns the parent Fs
@@ -517,28 +781,41 @@ func (o *Object) SetModTime(modTime time.Time) error {
 
 // Open an object for read
 func (o *Object) Open(options ...fs.OpenOption) (in io.ReadCloser, err error) {
-	// bigObject := o.Size() >= int64(tempLinkThreshold)
-	// if bigObject {
-	// 	fs.Debugf(o, "Downloading large object via tempLink")
-	// }
-	// file := acd.File{Node: o.info}
-	// var resp *http.Response
-	// headers := fs.OpenOptionHeaders(options)
-	// err = o.fs.pacer.Call(func() (bool, error) {
-	// 	if !bigObject {
-	// 		in, resp, err = file.OpenHeaders(headers)
-	// 	} else {
-	// 		in, resp, err = file.OpenTempURLHeaders(rest.ClientWithHeaderReset(o.fs.noAuthClient, headers), headers)
-	// 	}
-	// 	return o.fs.shouldRetry(resp, err)
-	// })
-	// return in, err
-	return nil, fmt.Errorf("Open not implemented")
+	headers := fs.OpenOptionHeaders(options)
+
+	var resp *http.Response
+	err = o.fs.pacer.Call(func() (bool, error) {
+		opts := rest.Opts{
+			Method:       "GET",
+			Path:         "/download/file.json/" + o.id,
+			Parameters:   url.Values{"session_id": {o.fs.sessionID()}},
+			ExtraHeaders: headers,
+		}
+		resp, err = o.fs.srv.Call(&opts)
+		return o.fs.shouldRetry(resp, err)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if md5 := resp.Header.Get("X-File-MD5"); md5 != "" {
+		o.md5 = md5
+	}
+
+	return resp.Body, nil
 }
 
 // Remove an object
 func (o *Object) Remove() error {
-	return fmt.Errorf("Remove not implemented")
+	return o.fs.pacer.Call(func() (bool, error) {
+		opts := rest.Opts{
+			Method:     "DELETE",
+			Path:       "/file.json/" + o.fs.sessionID() + "/" + o.id,
+			NoResponse: true,
+		}
+		resp, err := o.fs.srv.Call(&opts)
+		return o.fs.shouldRetry(resp, err)
+	})
 }
 
 // Storable returns a boolean showing whether this object storable
@@ -550,27 +827,131 @@ func (o *Object) Storable() bool {
 //
 // The new object may have been created if an error is returned
 func (o *Object) Update(in io.Reader, src fs.ObjectInfo) error {
-	// file := acd.File{Node: o.info}
-	// var info *acd.File
-	// var resp *http.Response
-	// var err error
-	// err = o.fs.pacer.CallNoRetry(func() (bool, error) {
-	// 	start := time.Now()
-	// 	o.fs.tokenRenewer.Start()
-	// 	info, resp, err = file.Overwrite(in)
-	// 	o.fs.tokenRenewer.Stop()
-	// 	var ok bool
-	// 	ok, info, err = o.fs.checkUpload(resp, in, src, info, err, time.Since(start))
-	// 	if ok {
-	// 		return false, nil
-	// 	}
-	// 	return o.fs.shouldRetry(resp, err)
-	// })
-	// if err != nil {
-	// 	return err
-	// }
-	// o.info = info.Node
-	// return nil
+	leaf, directoryID, err := o.fs.dirCache.FindPath(o.remote, true)
+	if err != nil {
+		return err
+	}
+
+	createFileRequest := CreateFileRequest{
+		SessionID: o.fs.sessionID(),
+		FolderID:  directoryID,
+		Name:      leaf,
+		Size:      src.Size(),
+	}
+	var createFileResponse CreateFileResponse
+	var resp *http.Response
+	err = o.fs.pacer.Call(func() (bool, error) {
+		opts := rest.Opts{
+			Method: "POST",
+			Path:   "/upload/create_file.json",
+		}
+		createFileRequest.SessionID = o.fs.sessionID()
+		resp, err = o.fs.srv.CallJSON(&opts, &createFileRequest, &createFileResponse)
+		return o.fs.shouldRetry(resp, err)
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to create file")
+	}
+	fileID := createFileResponse.FileID
+
+	hasher := md5.New()
+	chunkSize := int64(o.fs.uploadChunkSize)
+	buf := make([]byte, chunkSize)
+	var bytesWritten int64
+	parts := 0
+	for {
+		n, readErr := io.ReadFull(in, buf)
+		if n > 0 {
+			parts++
+			if parts > maxParts {
+				o.fs.removeFailedUpload(fileID)
+				return errors.Errorf("file too big: exceeds the maximum of %d chunks", maxParts)
+			}
+			chunk := buf[:n]
+			_, _ = hasher.Write(chunk)
+
+			err = o.fs.pacer.Call(func() (bool, error) {
+				resp, err = o.uploadChunk(fileID, createFileResponse.TempLocation, bytesWritten, chunk)
+				return o.fs.shouldRetry(resp, err)
+			})
+			if err != nil {
+				o.fs.removeFailedUpload(fileID)
+				return errors.Wrap(err, "failed to upload chunk")
+			}
+			bytesWritten += int64(n)
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			o.fs.removeFailedUpload(fileID)
+			return errors.Wrap(readErr, "failed to read source")
+		}
+	}
 
-	return fmt.Errorf("Update not implemented")
+	md5sum := hex.EncodeToString(hasher.Sum(nil))
+	closeRequest := CloseUploadRequest{
+		SessionID:    o.fs.sessionID(),
+		FileID:       fileID,
+		Size:         bytesWritten,
+		TempLocation: createFileResponse.TempLocation,
+		FileHash:     md5sum,
+	}
+	var closeResponse CloseUploadResponse
+	err = o.fs.pacer.Call(func() (bool, error) {
+		opts := rest.Opts{
+			Method: "POST",
+			Path:   "/upload/close_file_upload.json",
+		}
+		closeRequest.SessionID = o.fs.sessionID()
+		resp, err = o.fs.srv.CallJSON(&opts, &closeRequest, &closeResponse)
+		return o.fs.shouldRetry(resp, err)
+	})
+	if err != nil {
+		o.fs.removeFailedUpload(fileID)
+		return errors.Wrap(err, "failed to close upload")
+	}
+
+	o.id = fileID
+	o.size = bytesWritten
+	o.md5 = md5sum
+	o.modTime = time.Now()
+	return nil
+}
+
+// uploadChunk posts a single chunk of a file being uploaded as part of a
+// multipart form, as required by /upload/upload_file_chunk.json
+func (o *Object) uploadChunk(fileID, tempLocation string, offset int64, chunk []byte) (*http.Response, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for key, value := range map[string]string{
+		"session_id":    o.fs.sessionID(),
+		"file_id":       fileID,
+		"temp_location": tempLocation,
+		"chunk_offset":  strconv.FormatInt(offset, 10),
+		"chunk_size":    strconv.Itoa(len(chunk)),
+	} {
+		if err := writer.WriteField(key, value); err != nil {
+			return nil, err
+		}
+	}
+	part, err := writer.CreateFormFile("file_data", "chunk")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = part.Write(chunk); err != nil {
+		return nil, err
+	}
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+
+	opts := rest.Opts{
+		Method:      "POST",
+		Path:        "/upload/upload_file_chunk.json",
+		Body:        &body,
+		ContentType: writer.FormDataContentType(),
+	}
+	var reply UploadFileChunkReply
+	return o.fs.srv.CallJSON(&opts, nil, &reply)
 }